@@ -0,0 +1,39 @@
+package log
+
+import "context"
+
+type ctxKey struct{}
+
+// From returns the SugaredLogger attached to ctx by With/WithFields,
+// falling back to the package-level logger when none was attached.
+func From(ctx context.Context) *SugaredLogger {
+	if ctx == nil {
+		return sugger
+	}
+	if s, ok := ctx.Value(ctxKey{}).(*SugaredLogger); ok {
+		return s
+	}
+	return sugger
+}
+
+// With returns a copy of ctx carrying From(ctx) (the logger already attached
+// to ctx, or the package-level logger when none was) so that From can
+// recover it further down the call chain.
+func With(ctx context.Context) context.Context {
+	return WithFields(ctx, nil)
+}
+
+// WithFields returns a copy of ctx carrying a SugaredLogger that always logs
+// fs as structured key/value pairs, merged on top of any fields already
+// attached to ctx by an earlier With/WithFields call.
+func WithFields(ctx context.Context, fs Fields) context.Context {
+	s := From(ctx)
+	if len(fs) > 0 {
+		args := make([]interface{}, 0, len(fs)*2)
+		for k, v := range fs {
+			args = append(args, k, v)
+		}
+		s = &SugaredLogger{s.With(args...)}
+	}
+	return context.WithValue(ctx, ctxKey{}, s)
+}
@@ -0,0 +1,32 @@
+package log
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// LevelHandler serves a zap.AtomicLevel over HTTP: GET returns the current
+// level, PUT with a JSON body of {"level":"debug"} changes it live, letting
+// operators turn up verbosity without a restart.
+type LevelHandler struct {
+	level *zap.AtomicLevel
+}
+
+// NewLevelHandler wraps lvl. A nil lvl falls back to the package-level
+// logger's AtomicLevel, set via Init.
+func NewLevelHandler(lvl *zap.AtomicLevel) *LevelHandler {
+	if lvl == nil {
+		lvl = logger.config.AtomicLevel
+	}
+	return &LevelHandler{level: lvl}
+}
+
+// ServeHTTP implements http.Handler by delegating to the wrapped AtomicLevel.
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.level == nil {
+		http.Error(w, "log: atomic level not configured", http.StatusServiceUnavailable)
+		return
+	}
+	h.level.ServeHTTP(w, r)
+}
@@ -33,6 +33,29 @@ type Config struct {
 	Kafka         *KafkaConfig
 	WebHook       []*WebHookConfig
 	RotateDay     int
+
+	// Outputs routes individual level ranges to their own sink, e.g.
+	// error.log/access.log/crash.log, each with its own rotation policy.
+	// Each output adds its own core next to the Path/MaxSize/RotateDay hook
+	// above; that hook does not get replaced and still writes every enabled
+	// level whenever Path is set.
+	Outputs []OutputConfig
+
+	// AtomicLevel, when set, overrides Level as the core's level enabler and
+	// can be adjusted at runtime (see LevelHandler) without rebuilding the
+	// logger. New fills it in from Level when left nil.
+	AtomicLevel *zap.AtomicLevel
+
+	// Sampling caps how many identical level+message entries get logged per
+	// Tick, protecting downstream sinks from a runaway flood.
+	Sampling *SamplingConfig
+	// KafkaRateLimit/WebHookRateLimit guard the Kafka and webhook cores with
+	// a token bucket so a flood can't saturate those sinks either.
+	KafkaRateLimit   *RateLimitConfig
+	WebHookRateLimit *RateLimitConfig
+	// OnDrop, if set, is called for every entry suppressed by Sampling or by
+	// the Kafka/webhook rate limiters.
+	OnDrop func(zapcore.Entry)
 }
 
 // SugaredLogger ..
@@ -106,6 +129,11 @@ func New(config *Config) (*Logger, error) {
 		}
 	}
 
+	if config.AtomicLevel == nil {
+		al := zap.NewAtomicLevelAt(lvl)
+		config.AtomicLevel = &al
+	}
+
 	if config.Path != "" {
 		dir := getDir(config.Path)
 		if isPathNotExist(dir) {
@@ -186,22 +214,31 @@ func New(config *Config) (*Logger, error) {
 	cores = append(cores, zapcore.NewCore(
 		ecoder,
 		zapcore.NewMultiWriteSyncer(hooks...),
-		lvl,
+		config.AtomicLevel,
 	))
 
+	for _, out := range config.Outputs {
+		outCore, err := newOutputCore(out, ecoder)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, outCore)
+	}
+
 	for _, cfg := range config.WebHook {
-		cores = append(cores, NewWebHookCore(cfg, encoderConfig))
+		whCore := newRateLimitedCore(NewWebHookCore(cfg, encoderConfig), config.WebHookRateLimit, "webhook", config.OnDrop)
+		cores = append(cores, whCore)
 	}
 
 	if config.Kafka != nil {
-		core, err := NewKafkaCore(config, encoderConfig)
+		kCore, err := NewKafkaCore(config, encoderConfig)
 		if err != nil {
 			return nil, err
 		}
-		cores = append(cores, core)
+		cores = append(cores, newRateLimitedCore(kCore, config.KafkaRateLimit, "kafka", config.OnDrop))
 	}
 
-	core := zapcore.NewTee(cores...)
+	core := sampleCore(zapcore.NewTee(cores...), config)
 	var l *zap.Logger
 	l = zap.New(core)
 	if config.Caller {
@@ -0,0 +1,175 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// RotateConfig controls how an OutputConfig's file is rotated. MaxDay takes
+// precedence over MaxSize when both are set, unlike the top-level
+// Config.MaxSize/RotateDay, where New writes through both hooks.
+type RotateConfig struct {
+	MaxSize int // MB, lumberjack size-based rotation
+	MaxDay  int // rotatelogs day-based rotation
+}
+
+// OutputConfig routes a level range to its own sink, each with an
+// independent rotation policy, for callers that need per-level files such as
+// error.log, access.log, crash.log. Each entry of Config.Outputs is written
+// in addition to the single Path/MaxSize/RotateDay hook, not instead of it.
+type OutputConfig struct {
+	Path     string
+	MinLevel string
+	MaxLevel string
+	Rotate   RotateConfig
+	Compress bool
+	MaxAge   int // days the rotator keeps a backup before deleting it; the compressor gzips well before this, see compressAge
+}
+
+func newOutputCore(cfg OutputConfig, ecoder zapcore.Encoder) (zapcore.Core, error) {
+	dir := getDir(cfg.Path)
+	if isPathNotExist(dir) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	var ws zapcore.WriteSyncer
+	if cfg.Rotate.MaxDay != 0 {
+		fn := cfg.Path
+		if !filepath.IsAbs(fn) {
+			v, err := filepath.Abs(fn)
+			if err != nil {
+				return nil, err
+			}
+			fn = v
+		}
+		rl, err := rotatelogs.New(
+			fn+".%Y%m%d",
+			rotatelogs.WithLinkName(fn),
+			rotatelogs.WithMaxAge(time.Hour*24*time.Duration(cfg.MaxAge)),
+			rotatelogs.WithRotationTime(time.Hour*24*time.Duration(cfg.Rotate.MaxDay)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		ws = zapcore.AddSync(rl)
+	} else {
+		ws = zapcore.AddSync(&lumberjack.Logger{
+			Filename: cfg.Path,
+			MaxSize:  cfg.Rotate.MaxSize,
+			MaxAge:   cfg.MaxAge,
+			// gzip handled by startCompressor below, not by lumberjack itself
+		})
+	}
+
+	min := zapcore.DebugLevel
+	if cfg.MinLevel != "" {
+		min = ParseLevel(cfg.MinLevel)
+	}
+	max := zapcore.FatalLevel
+	if cfg.MaxLevel != "" {
+		max = ParseLevel(cfg.MaxLevel)
+	}
+
+	startCompressor(cfg)
+
+	return zapcore.NewCore(ecoder, ws, levelRange(min, max)), nil
+}
+
+func levelRange(min, max zapcore.Level) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl >= min && lvl <= max
+	})
+}
+
+// startCompressor launches a background goroutine that gzips cfg.Path's
+// rotated backups once they're older than compressAge(cfg), then removes
+// the uncompressed original. A no-op when Compress or MaxAge aren't set.
+func startCompressor(cfg OutputConfig) {
+	if !cfg.Compress || cfg.MaxAge <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			compressOldBackups(cfg)
+		}
+	}()
+}
+
+// compressOldBackups globs cfg.Path's rotated backups, matching whichever
+// naming scheme the configured rotator actually produces: rotatelogs
+// appends ".%Y%m%d" to the full path, while lumberjack inserts a timestamp
+// before the extension (app.log -> app-2006-01-02T15-04-05.000.log).
+func compressOldBackups(cfg OutputConfig) {
+	pattern := cfg.Path + ".*"
+	if cfg.Rotate.MaxDay == 0 {
+		ext := filepath.Ext(cfg.Path)
+		base := strings.TrimSuffix(cfg.Path, ext)
+		pattern = base + "-*" + ext
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -compressAge(cfg))
+	for _, m := range matches {
+		if strings.HasSuffix(m, ".gz") {
+			continue
+		}
+		info, err := os.Stat(m)
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := gzipFile(m); err == nil {
+			os.Remove(m)
+		}
+	}
+}
+
+// compressAge is the age, in days, at which compressOldBackups gzips a
+// backup. It's deliberately half of cfg.MaxAge (floor 1) rather than
+// cfg.MaxAge itself: the rotator deletes backups at MaxAge, so compressing
+// at that same cutoff only ever catches files the rotator is already about
+// to remove, racing the deletion for no retention benefit.
+func compressAge(cfg OutputConfig) int {
+	age := cfg.MaxAge / 2
+	if age < 1 {
+		age = 1
+	}
+	return age
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	if _, err := io.Copy(gw, in); err != nil {
+		gw.Close()
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+)
+
+var droppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "toolkits",
+	Subsystem: "log",
+	Name:      "dropped_total",
+	Help:      "Log entries dropped by sampling or rate limiting, by core.",
+}, []string{"core"})
+
+func init() {
+	prometheus.MustRegister(droppedTotal)
+}
+
+// SamplingConfig configures zapcore's message-based sampler: of the entries
+// sharing a level+message within Tick, Initial are logged verbatim, then
+// every Thereafter'th one after that. The rest are dropped.
+type SamplingConfig struct {
+	Initial    int
+	Thereafter int
+	Tick       time.Duration
+}
+
+// RateLimitConfig guards a core with a token bucket so a runaway flood
+// can't saturate the sink behind it.
+type RateLimitConfig struct {
+	Rate  float64 // events per second
+	Burst int
+}
+
+func sampleCore(core zapcore.Core, cfg *Config) zapcore.Core {
+	if cfg.Sampling == nil {
+		return core
+	}
+
+	opts := []zapcore.SamplerOption{
+		zapcore.SamplerHook(func(entry zapcore.Entry, decision zapcore.SamplingDecision) {
+			if decision&zapcore.LogDropped == 0 {
+				return
+			}
+			droppedTotal.WithLabelValues("sampler").Inc()
+			if cfg.OnDrop != nil {
+				cfg.OnDrop(entry)
+			}
+		}),
+	}
+
+	return zapcore.NewSamplerWithOptions(
+		core,
+		cfg.Sampling.Tick,
+		cfg.Sampling.Initial,
+		cfg.Sampling.Thereafter,
+		opts...,
+	)
+}
+
+// rateLimitedCore drops entries instead of writing them once limiter is
+// exhausted, incrementing droppedTotal{core=name} and invoking onDrop.
+type rateLimitedCore struct {
+	zapcore.Core
+	limiter *rate.Limiter
+	name    string
+	onDrop  func(zapcore.Entry)
+}
+
+func newRateLimitedCore(core zapcore.Core, cfg *RateLimitConfig, name string, onDrop func(zapcore.Entry)) zapcore.Core {
+	if cfg == nil {
+		return core
+	}
+	return &rateLimitedCore{
+		Core:    core,
+		limiter: rate.NewLimiter(rate.Limit(cfg.Rate), cfg.Burst),
+		name:    name,
+		onDrop:  onDrop,
+	}
+}
+
+func (c *rateLimitedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitedCore{
+		Core:    c.Core.With(fields),
+		limiter: c.limiter,
+		name:    c.name,
+		onDrop:  c.onDrop,
+	}
+}
+
+func (c *rateLimitedCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return ce
+	}
+	return ce.AddCore(entry, c)
+}
+
+func (c *rateLimitedCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if !c.limiter.Allow() {
+		droppedTotal.WithLabelValues(c.name).Inc()
+		if c.onDrop != nil {
+			c.onDrop(entry)
+		}
+		return nil
+	}
+	return c.Core.Write(entry, fields)
+}
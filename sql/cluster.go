@@ -0,0 +1,177 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+
+	goqu "github.com/doug-martin/goqu/v9"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	replicaCheckInterval = 5 * time.Second
+	replicaFailThreshold = 3
+)
+
+// ShardConfig is one shard in Config.Shards. Key identifies the shard for
+// OnShard's lookup; URL/Replicas configure its own primary/replica set the
+// same way the top-level Config does.
+type ShardConfig struct {
+	Key      string
+	URL      string
+	Replicas []string
+}
+
+// replica tracks one replica connection's health, ejecting it from
+// clusterConn.pick's rotation after replicaFailThreshold consecutive Ping
+// failures and restoring it once a Ping succeeds again. healthy's zero
+// value is 0 (not in rotation), so a replica only joins pick's rotation
+// once it's passed at least one Ping.
+type replica struct {
+	db      *sql.DB
+	healthy int32 // atomic bool, 1 = in rotation
+	fails   int32
+}
+
+func (r *replica) checkHealth() {
+	if err := r.db.Ping(); err != nil {
+		if atomic.AddInt32(&r.fails, 1) >= replicaFailThreshold {
+			atomic.StoreInt32(&r.healthy, 0)
+		}
+		return
+	}
+	atomic.StoreInt32(&r.fails, 0)
+	atomic.StoreInt32(&r.healthy, 1)
+}
+
+func startHealthChecks(replicas []*replica) {
+	for _, r := range replicas {
+		r := r
+		go func() {
+			ticker := time.NewTicker(replicaCheckInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				r.checkHealth()
+			}
+		}()
+	}
+}
+
+// clusterConn implements gorm's SQLCommon interface so gorm.Open can use it
+// directly as a drop-in connection: reads (Query/QueryRow) are
+// load-balanced across healthy replicas, while writes (Exec/Prepare) always
+// go to the primary.
+type clusterConn struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     uint32
+}
+
+func (c *clusterConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.primary.Exec(query, args...)
+}
+
+func (c *clusterConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.primary.Prepare(query)
+}
+
+func (c *clusterConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.pick().Query(query, args...)
+}
+
+func (c *clusterConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.pick().QueryRow(query, args...)
+}
+
+// Begin/BeginTx/*Context variants exist so clusterConn also satisfies
+// goqu's SQLDatabase interface, which goqu.New requires in addition to
+// gorm's plain SQLCommon. They are never reached for TransactionCtx, which
+// always pins to the primary directly.
+func (c *clusterConn) Begin() (*sql.Tx, error) {
+	return c.primary.Begin()
+}
+
+func (c *clusterConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.primary.BeginTx(ctx, opts)
+}
+
+func (c *clusterConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.primary.ExecContext(ctx, query, args...)
+}
+
+func (c *clusterConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return c.primary.PrepareContext(ctx, query)
+}
+
+func (c *clusterConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.pick().QueryContext(ctx, query, args...)
+}
+
+func (c *clusterConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.pick().QueryRowContext(ctx, query, args...)
+}
+
+func (c *clusterConn) pick() *sql.DB {
+	n := len(c.replicas)
+	for i := 0; i < n; i++ {
+		idx := int(atomic.AddUint32(&c.next, 1)) % n
+		r := c.replicas[idx]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r.db
+		}
+	}
+	return c.primary
+}
+
+// openCluster opens cfg.Replicas and wraps primary and the replicas in a
+// clusterConn, returning the gorm/goqu handles DataBase.Gorm/Goqu hand out
+// when replica routing is enabled.
+func openCluster(cfg *Config, primary *sql.DB) (*gorm.DB, *goqu.Database, error) {
+	replicas := make([]*replica, 0, len(cfg.Replicas))
+	for _, url := range cfg.Replicas {
+		conn, err := sql.Open(cfg.Dialect, url)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfg.MaxOpenConns != 0 {
+			conn.SetMaxOpenConns(cfg.MaxOpenConns)
+		}
+		if cfg.MaxIdleConns != 0 {
+			conn.SetMaxIdleConns(cfg.MaxIdleConns)
+		}
+		if cfg.ConnMaxLifetime != 0 {
+			conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		}
+
+		r := &replica{db: conn}
+		// r.healthy starts at its zero value (unhealthy); this initial
+		// check lets a reachable replica join pick()'s rotation right away
+		// instead of after the first replicaCheckInterval tick, while an
+		// unreachable one stays out of it.
+		r.checkHealth()
+		replicas = append(replicas, r)
+	}
+	startHealthChecks(replicas)
+
+	conn := &clusterConn{primary: primary, replicas: replicas}
+
+	routed, err := gorm.Open(cfg.Dialect, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	routed.SingularTable(true)
+	if cfg.Debug {
+		routed.LogMode(true)
+	}
+
+	return routed, goqu.New(cfg.Dialect, conn), nil
+}
+
+func shardIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()) % n
+}
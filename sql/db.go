@@ -38,6 +38,14 @@ type Config struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	TransTimeout    time.Duration
+
+	// Replicas, when set, enables read/write split: Gorm()/Goqu() load-balance
+	// SELECTs across the healthy ones, while writes and TransactionCtx always
+	// use URL as the primary.
+	Replicas []string
+	// Shards, when set, enables DataBase.OnShard(key) routing to an
+	// independently-configured primary/replica set per shard.
+	Shards []ShardConfig
 }
 
 // Inject init db conns, panic if fail
@@ -74,11 +82,42 @@ func Open(cfg *Config) (*DataBase, error) {
 		db.DB().SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	}
 
-	return &DataBase{
+	dataBase := &DataBase{
 		DB:   db,
 		cfg:  cfg,
 		goqu: goqu.New(cfg.Dialect, db.DB()),
-	}, nil
+	}
+
+	if len(cfg.Replicas) > 0 {
+		routed, routedGoqu, err := openCluster(cfg, db.DB())
+		if err != nil {
+			return nil, err
+		}
+		dataBase.routed = routed
+		dataBase.routedGoqu = routedGoqu
+	}
+
+	if len(cfg.Shards) > 0 {
+		dataBase.shardByKey = make(map[string]*DataBase, len(cfg.Shards))
+		for _, sc := range cfg.Shards {
+			shard, err := Open(&Config{
+				Dialect:         cfg.Dialect,
+				URL:             sc.URL,
+				Replicas:        sc.Replicas,
+				MaxOpenConns:    cfg.MaxOpenConns,
+				MaxIdleConns:    cfg.MaxIdleConns,
+				ConnMaxLifetime: cfg.ConnMaxLifetime,
+				TransTimeout:    cfg.TransTimeout,
+			})
+			if err != nil {
+				return nil, err
+			}
+			dataBase.shards = append(dataBase.shards, shard)
+			dataBase.shardByKey[sc.Key] = shard
+		}
+	}
+
+	return dataBase, nil
 }
 
 // DataBase ...
@@ -86,18 +125,54 @@ type DataBase struct {
 	*gorm.DB
 	cfg  *Config
 	goqu *goqu.Database
+
+	// routed/routedGoqu, when non-nil, are the cluster-aware handles Gorm()
+	// and Goqu() hand out instead of the primary-only DB/goqu above.
+	routed     *gorm.DB
+	routedGoqu *goqu.Database
+
+	shards     []*DataBase
+	shardByKey map[string]*DataBase
 }
 
-// Gorm ...
+// Gorm returns the gorm handle used for day-to-day queries: cluster-aware
+// (SELECTs load-balanced across replicas) when Config.Replicas is set,
+// otherwise the plain primary connection.
 func (db *DataBase) Gorm() *gorm.DB {
+	if db.routed != nil {
+		return db.routed
+	}
 	return db.DB
 }
 
-// Goqu ...
+// Goqu mirrors Gorm for the goqu query builder.
 func (db *DataBase) Goqu() *goqu.Database {
+	if db.routedGoqu != nil {
+		return db.routedGoqu
+	}
 	return db.goqu
 }
 
+// ForcePrimary returns a handle that always talks to the primary, bypassing
+// replica routing entirely. Use it for read-your-writes immediately after a
+// write, since a just-committed write may not have reached a replica yet.
+func (db *DataBase) ForcePrimary() *gorm.DB {
+	return db.DB
+}
+
+// OnShard returns the DataBase for key: an exact Config.Shards[i].Key match
+// if one exists, otherwise the shard chosen by hashing key across the
+// configured shards. Returns db itself when no shards are configured.
+func (db *DataBase) OnShard(key string) *DataBase {
+	if len(db.shards) == 0 {
+		return db
+	}
+	if shard, ok := db.shardByKey[key]; ok {
+		return shard
+	}
+	return db.shards[shardIndex(key, len(db.shards))]
+}
+
 // Begin ..
 func (db *DataBase) Begin() *gorm.DB {
 	return db.DB.Begin()
@@ -114,19 +189,43 @@ func (db *DataBase) Rollback() *gorm.DB {
 }
 
 // Transaction ...
-func (db *DataBase) Transaction(f func(*gorm.DB) error) (err error) {
+func (db *DataBase) Transaction(f func(ctx context.Context, tx *gorm.DB) error) (err error) {
 	return db.TransactionCtx(context.Background(), f)
 }
 
-// TransactionCtx ...
-func (db *DataBase) TransactionCtx(ctx context.Context, f func(*gorm.DB) error) (err error) {
+// TransactionCtx runs f inside a transaction. Passing a TxOptions as opt
+// controls isolation and enables automatic retry on MySQL deadlock (1213)
+// and lock-wait timeout (1205) errors; serialization-failure codes of other
+// drivers are not recognized and are not retried. Omitting it keeps the
+// previous single-attempt behavior.
+//
+// Nesting is automatic: the ctx passed to f already carries the in-flight
+// tx (via WithTx), so a nested TransactionCtx(ctx, ...) call made from
+// inside f — using that same ctx — opens a real savepoint instead of a
+// separate transaction. Callers only need WithTx themselves when handing
+// the tx across an API boundary that doesn't thread ctx through, e.g.
+// starting a goroutine or calling code that takes a bare *gorm.DB.
+func (db *DataBase) TransactionCtx(ctx context.Context, f func(ctx context.Context, tx *gorm.DB) error, opt ...TxOptions) (err error) {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return runInSavepoint(ctx, tx, f)
+	}
+
+	var o TxOptions
+	if len(opt) > 0 {
+		o = opt[0]
+	}
+	return db.transactionWithRetry(ctx, o, f)
+}
+
+func (db *DataBase) runTransaction(ctx context.Context, o TxOptions, f func(ctx context.Context, tx *gorm.DB) error) (err error) {
 	var tx *gorm.DB
+	txOpts := &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
 	if _, ok := ctx.Deadline(); db.cfg.TransTimeout != 0 && !ok {
 		ctxt, cancel := context.WithTimeout(ctx, db.cfg.TransTimeout)
 		defer cancel()
-		tx = db.BeginTx(ctxt, &sql.TxOptions{})
+		tx = db.BeginTx(ctxt, txOpts)
 	} else {
-		tx = db.BeginTx(ctx, &sql.TxOptions{})
+		tx = db.BeginTx(ctx, txOpts)
 	}
 
 	defer func() {
@@ -137,7 +236,11 @@ func (db *DataBase) TransactionCtx(ctx context.Context, f func(*gorm.DB) error)
 		}
 	}()
 
-	err = f(tx)
+	// WithTx here is what makes nesting automatic: f (and anything f
+	// calls with this ctx) sees tx via ctx.Value(txKey{}), so a nested
+	// TransactionCtx call reaches the savepoint branch above instead of
+	// opening an independent transaction on a separate connection.
+	err = f(WithTx(ctx, tx), tx)
 	if err != nil {
 		tx.Rollback()
 		return
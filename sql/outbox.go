@@ -0,0 +1,276 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	outboxLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "toolkits",
+		Subsystem: "outbox",
+		Name:      "lag",
+		Help:      "Number of unpublished outbox events currently due for delivery.",
+	})
+	outboxFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "toolkits",
+		Subsystem: "outbox",
+		Name:      "failures_total",
+		Help:      "Outbox events that failed to publish, by topic.",
+	}, []string{"topic"})
+	outboxDeadLettered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "toolkits",
+		Subsystem: "outbox",
+		Name:      "dead_lettered_total",
+		Help:      "Outbox events retired after exhausting MaxRetries, by topic.",
+	}, []string{"topic"})
+)
+
+func init() {
+	prometheus.MustRegister(outboxLag, outboxFailures, outboxDeadLettered)
+}
+
+// OutboxEvent is a row in the outbox table. It is inserted in the same
+// transaction as the business write it accompanies and later delivered by
+// Dispatcher with at-least-once semantics.
+type OutboxEvent struct {
+	ID          int64 `gorm:"primary_key"`
+	Topic       string
+	Payload     []byte
+	Retries     int
+	NextAttempt time.Time
+	PublishedAt *time.Time
+	// FailedAt is set once Retries exhausts DispatcherConfig.MaxRetries,
+	// retiring the row from claim's due set so a permanently failing sink
+	// can't re-lease it forever. Nil means still eligible for delivery.
+	FailedAt  *time.Time
+	CreatedAt time.Time
+}
+
+// TableName ...
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// Publisher delivers a single outbox event to its sink, e.g. Kafka or a
+// webhook. Dispatcher calls Publish again, with the same event, after a
+// backoff when it returns an error.
+type Publisher interface {
+	Publish(ctx context.Context, event *OutboxEvent) error
+}
+
+// Outbox enqueues payload under topic as part of tx, so it is only
+// considered for delivery if the surrounding TransactionCtx commits. Call
+// it from inside the closure passed to Transaction/TransactionCtx.
+func Outbox(tx *gorm.DB, topic string, payload []byte) error {
+	return tx.Create(&OutboxEvent{
+		Topic:       topic,
+		Payload:     payload,
+		NextAttempt: time.Now(),
+		CreatedAt:   time.Now(),
+	}).Error
+}
+
+// DispatcherConfig configures a Dispatcher.
+type DispatcherConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// MaxBackoff caps the exponential delay between retries, so a
+	// persistently failing publisher doesn't push NextAttempt out to
+	// absurd (and, past a 1<<63 shift, overflowed/negative) durations.
+	MaxBackoff time.Duration
+}
+
+// maxBackoffShift bounds the exponent in the retry backoff calculation so
+// RetryBackoff*(1<<shift) can't overflow time.Duration before MaxBackoff
+// even gets a chance to clamp it.
+const maxBackoffShift = 20
+
+// Dispatcher polls the outbox table and publishes due events via Publisher,
+// retrying failed rows with exponential backoff (capped at MaxBackoff)
+// until MaxRetries is hit, at which point the row is dead-lettered
+// (FailedAt set) instead of being retried forever.
+type Dispatcher struct {
+	db        *DataBase
+	publisher Publisher
+	cfg       DispatcherConfig
+
+	stop chan struct{}
+}
+
+// NewDispatcher builds a Dispatcher. Zero-valued fields on cfg fall back to
+// sensible defaults (100 events/poll, a 1s poll interval, unlimited
+// retries, 1s initial backoff, 5m max backoff).
+func NewDispatcher(db *DataBase, publisher Publisher, cfg DispatcherConfig) *Dispatcher {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	return &Dispatcher{
+		db:        db,
+		publisher: publisher,
+		cfg:       cfg,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Run polls until ctx is done or Stop is called, publishing due events on
+// every tick. It also returns, with that error, as soon as a poll fails to
+// count or claim due events.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-d.stop:
+			return nil
+		case <-ticker.C:
+			if err := d.dispatchOnce(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Stop ends a running Run loop.
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+}
+
+// dispatchOnce claims a batch of due events in a short transaction, then
+// publishes them one at a time with the row lock already released — a slow
+// or hung sink must never hold FOR UPDATE SKIP LOCKED open across network
+// I/O.
+func (d *Dispatcher) dispatchOnce(ctx context.Context) error {
+	if err := d.reportLag(ctx); err != nil {
+		return err
+	}
+
+	events, err := d.claim(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		d.publishOne(ctx, &events[i])
+	}
+	return nil
+}
+
+// reportLag gauges the full due backlog (not just what this poll claims),
+// so outboxLag reflects real backlog depth even once it exceeds BatchSize.
+func (d *Dispatcher) reportLag(ctx context.Context) error {
+	var count int
+	err := d.db.Gorm().Model(&OutboxEvent{}).
+		Where("published_at IS NULL AND failed_at IS NULL AND next_attempt <= ?", time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return errors.Wrap(err, "sql: count outbox backlog")
+	}
+	outboxLag.Set(float64(count))
+	return nil
+}
+
+// claim selects up to BatchSize due rows with FOR UPDATE SKIP LOCKED and
+// immediately leases them by pushing NextAttempt out, then commits. That
+// bounds how long a crash between claim and publishOne's final write can
+// delay redelivery, without keeping the rows locked for the claiming
+// connection's lifetime. Rows already retired by publishOne (FailedAt set)
+// are excluded so a dead-lettered event can't be re-leased forever.
+func (d *Dispatcher) claim(ctx context.Context) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := d.db.TransactionCtx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Raw(
+			`SELECT * FROM outbox WHERE published_at IS NULL AND failed_at IS NULL AND next_attempt <= ? ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED`,
+			time.Now(), d.cfg.BatchSize,
+		).Scan(&events).Error; err != nil {
+			return err
+		}
+
+		lease := time.Now().Add(d.leaseDuration())
+		for i := range events {
+			if err := tx.Model(&OutboxEvent{}).Where("id = ?", events[i].ID).
+				Update("next_attempt", lease).Error; err != nil {
+				return err
+			}
+			events[i].NextAttempt = lease
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "sql: claim outbox events")
+	}
+	return events, nil
+}
+
+// leaseDuration is how long a claimed-but-not-yet-published row is hidden
+// from other dispatchers.
+func (d *Dispatcher) leaseDuration() time.Duration {
+	lease := d.cfg.PollInterval * 10
+	if lease < d.cfg.RetryBackoff {
+		lease = d.cfg.RetryBackoff
+	}
+	return lease
+}
+
+// publishOne publishes ev outside of any transaction and records the
+// outcome in its own single-row write. PublishedAt is only ever set after
+// Publish succeeds, so a write failure here just leaves the row to be
+// reclaimed once its lease expires — a harmless at-least-once redelivery,
+// never a record of a publish that didn't happen. Once Retries exhausts
+// MaxRetries, the row is dead-lettered (FailedAt set) instead of being
+// left to re-lease forever with no path out of the due set.
+func (d *Dispatcher) publishOne(ctx context.Context, ev *OutboxEvent) {
+	if d.cfg.MaxRetries != 0 && ev.Retries >= d.cfg.MaxRetries {
+		now := time.Now()
+		ev.FailedAt = &now
+		outboxDeadLettered.WithLabelValues(ev.Topic).Inc()
+		d.db.Gorm().Save(ev)
+		return
+	}
+
+	if err := d.publisher.Publish(ctx, ev); err != nil {
+		ev.Retries++
+		ev.NextAttempt = time.Now().Add(d.backoff(ev.Retries))
+		outboxFailures.WithLabelValues(ev.Topic).Inc()
+		d.db.Gorm().Save(ev)
+		return
+	}
+
+	now := time.Now()
+	ev.PublishedAt = &now
+	d.db.Gorm().Save(ev)
+}
+
+// backoff computes the exponential delay before the given retry attempt,
+// capping both the exponent (so the shift itself can't overflow) and the
+// result (so a persistently failing publisher converges on MaxBackoff
+// instead of freezing the event for increasingly absurd durations).
+func (d *Dispatcher) backoff(retries int) time.Duration {
+	shift := retries - 1
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	delay := d.cfg.RetryBackoff * time.Duration(1<<uint(shift))
+	if d.cfg.MaxBackoff != 0 && delay > d.cfg.MaxBackoff {
+		return d.cfg.MaxBackoff
+	}
+	return delay
+}
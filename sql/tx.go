@@ -0,0 +1,120 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+// mysql error numbers that are safe to retry: deadlock found and lock wait
+// timeout exceeded. There is no generic "serialization failure" code on
+// MySQL the way there is on Postgres (SQLSTATE 40001) — these two are what
+// MySQL's deadlock detector and innodb_lock_wait_timeout actually raise,
+// so that's the limit of what isRetryableTxError recognizes today. A
+// Postgres dialect would need its own check added here.
+const (
+	mysqlErrDeadlock    = 1213
+	mysqlErrLockTimeout = 1205
+)
+
+// TxOptions configures TransactionCtx's isolation and retry behavior.
+type TxOptions struct {
+	Isolation    sql.IsolationLevel
+	ReadOnly     bool
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+type txKey struct{}
+
+// WithTx returns a copy of ctx carrying tx. A TransactionCtx call given that
+// ctx opens a real savepoint on tx instead of a new transaction, so nested
+// transactional code can roll back independently of the outer one.
+// runTransaction calls this on f's ctx automatically, so ordinary nested
+// TransactionCtx(ctx, ...) calls made from inside f already get this for
+// free; call it yourself only when handing tx to code that won't see that
+// ctx, e.g. across a goroutine or an API that takes a bare *gorm.DB.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Savepoint creates a named savepoint within tx.
+func Savepoint(tx *gorm.DB, name string) error {
+	return tx.Exec("SAVEPOINT " + name).Error
+}
+
+// RollbackTo rolls tx back to a savepoint previously created with Savepoint.
+func RollbackTo(tx *gorm.DB, name string) error {
+	return tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error
+}
+
+func runInSavepoint(ctx context.Context, tx *gorm.DB, f func(context.Context, *gorm.DB) error) (err error) {
+	name := fmt.Sprintf("sp_%d", time.Now().UnixNano())
+	if err = Savepoint(tx, name); err != nil {
+		return err
+	}
+
+	if err = f(ctx, tx); err != nil {
+		if rerr := RollbackTo(tx, name); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	return nil
+}
+
+// transactionWithRetry runs f in a fresh transaction, re-running it from
+// scratch with a fresh *gorm.DB whenever the driver reports a retryable
+// error, up to o.MaxRetries times and never past ctx's deadline.
+func (db *DataBase) transactionWithRetry(ctx context.Context, o TxOptions, f func(context.Context, *gorm.DB) error) (err error) {
+	backoff := o.RetryBackoff
+	if backoff == 0 {
+		backoff = 50 * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = db.runTransaction(ctx, o, f)
+		if err == nil || attempt >= o.MaxRetries || !isRetryableTxError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff * time.Duration(1<<uint(attempt))):
+		}
+	}
+}
+
+func isRetryableTxError(err error) bool {
+	me, ok := errorsCause(err).(*mysqldriver.MySQLError)
+	if !ok {
+		return false
+	}
+	switch me.Number {
+	case mysqlErrDeadlock, mysqlErrLockTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// causer mirrors github.com/pkg/errors' Causer so we can unwrap without
+// forcing every caller to use that package's error type.
+type causer interface {
+	Cause() error
+}
+
+func errorsCause(err error) error {
+	for {
+		c, ok := err.(causer)
+		if !ok {
+			return err
+		}
+		err = c.Cause()
+	}
+}